@@ -1,25 +1,37 @@
 package main
 
 import (
+	"bufio"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"os"
+	"sort"
+	"strings"
+
+	"github.com/ParkWardRR/PacketParamedic/tools/blame-trainer/internal/feature"
+	internalmodel "github.com/ParkWardRR/PacketParamedic/tools/blame-trainer/internal/model"
 )
 
-type LogisticModel struct {
-	FeatureNames []string    `json:"feature_names"`
-	ClassNames   []string    `json:"class_names"`
-	Weights      [][]float64 `json:"weights"` // [n_classes][n_features]
-	Bias         []float64   `json:"bias"`    // [n_classes]
-	Means        []float64   `json:"means"`   // For standardization
-	Stds         []float64   `json:"stds"`    // For standardization
-}
+// Metrics and LogisticModel mirror the trainer's artifact types; see
+// internal/model.
+type (
+	Metrics       = internalmodel.Metrics
+	LogisticModel = internalmodel.LogisticModel
+)
 
 func main() {
 	modelPath := flag.String("model", "blame_lr.json", "Path to model JSON")
+	temperature := flag.Float64("temperature", 1.0, "Softmax temperature; logits are divided by this before softmax (>1 flattens, <1 sharpens)")
+	abstainThreshold := flag.Float64("abstain-threshold", 0.0, "If the top class probability is below this, or the top-2 margin is below -margin, emit \"unknown\" instead of a verdict (0 disables)")
+	margin := flag.Float64("margin", 0.0, "Minimum gap between the top-2 class probabilities required to avoid abstaining")
+	topK := flag.Int("topk", 0, "Print the top-K ranked predictions with probabilities (0 disables)")
+	explain := flag.Bool("explain", false, "Print per-feature standardized contributions to the predicted class, sorted by magnitude")
+	stdinMode := flag.Bool("stdin", false, "Read newline-delimited JSON Feature objects from stdin and emit one JSON prediction per line")
+	jsonlOut := flag.String("jsonl-out", "", "With -stdin, write JSONL results here instead of stdout")
 	flag.Parse()
 
 	bytes, err := os.ReadFile(*modelPath)
@@ -32,6 +44,11 @@ func main() {
 		log.Fatalf("Failed to parse model: %v", err)
 	}
 
+	if *stdinMode {
+		runStdin(model, *temperature, *abstainThreshold, *margin, *jsonlOut)
+		return
+	}
+
 	// Hardcoded example (ISP Failure Pattern)
 	// GW ok (2ms), WAN bad (100ms), Delta large (98ms), minimal loss, some DNS slow
 	feats := []float64{
@@ -44,38 +61,289 @@ func main() {
 	}
 
 	// 1. Standardize
+	normFeats := standardize(model, feats)
+
+	// 2. Predict probabilities (temperature-scaled softmax)
+	probs := softmax(model, normFeats, *temperature)
+
+	fmt.Println("Prediction for synthetic ISP-failure sample:")
+
+	verdict, reason := classify(model.ClassNames, probs, *abstainThreshold, *margin)
+	if verdict == "unknown" {
+		fmt.Printf("  verdict: unknown (%s)\n", reason)
+	} else {
+		fmt.Printf("  verdict: %s\n", verdict)
+	}
+
+	if *topK > 0 {
+		printTopK(model.ClassNames, probs, *topK)
+	} else {
+		for k, name := range model.ClassNames {
+			fmt.Printf("  %s: %.4f\n", name, probs[k])
+		}
+	}
+
+	if *explain {
+		predicted := argmax(probs)
+		printExplanation(model, normFeats, predicted)
+	}
+
+	printModelQuality(model.Metrics)
+}
+
+// standardize applies the model's stored means/stds to a raw feature vector.
+func standardize(model LogisticModel, feats []float64) []float64 {
 	normFeats := make([]float64, len(feats))
 	for i, v := range feats {
 		normFeats[i] = (v - model.Means[i]) / model.Stds[i]
 	}
+	return normFeats
+}
 
-	// 2. Predict Probabilities (Softmax)
-	var scores []float64
-	maxScore := -1e9
+// PredictionResult is the JSON shape emitted once per line in -stdin mode.
+type PredictionResult struct {
+	ClassProbs map[string]float64 `json:"class_probs"`
+	Predicted  string             `json:"predicted"`
+	Margin     float64            `json:"margin"`
+	Abstain    bool               `json:"abstain,omitempty"`
+	Reason     string             `json:"reason,omitempty"`
+}
+
+// runStdin reads newline-delimited JSON Feature objects from stdin and
+// writes one PredictionResult JSON object per line, enabling the Rust
+// collector (or anything else) to pipe live telemetry through the
+// classifier as a long-running process instead of re-invoking it per
+// sample.
+func runStdin(model LogisticModel, temperature, abstainThreshold, margin float64, jsonlOutPath string) {
+	var out io.Writer = os.Stdout
+	if jsonlOutPath != "" {
+		f, err := os.Create(jsonlOutPath)
+		if err != nil {
+			log.Fatalf("Failed to open -jsonl-out path: %v", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	enc := json.NewEncoder(out)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var f feature.Feature
+		if err := json.Unmarshal([]byte(line), &f); err != nil {
+			log.Printf("skipping malformed line: %v", err)
+			continue
+		}
+
+		normFeats := standardize(model, f.ToVector())
+		probs := softmax(model, normFeats, temperature)
+		first, second := rankTop2(probs)
+		verdict, reason := classify(model.ClassNames, probs, abstainThreshold, margin)
+
+		classProbs := make(map[string]float64, len(model.ClassNames))
+		for k, name := range model.ClassNames {
+			classProbs[name] = probs[k]
+		}
+
+		result := PredictionResult{
+			ClassProbs: classProbs,
+			Predicted:  verdict,
+			Margin:     probs[first] - probs[second],
+			Abstain:    verdict == "unknown",
+			Reason:     reason,
+		}
+
+		if err := enc.Encode(result); err != nil {
+			log.Fatalf("Failed to write result: %v", err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Error reading stdin: %v", err)
+	}
+}
+
+// computeScores evaluates the model's raw (pre-softmax) class scores for a
+// standardized feature vector, switching on ModelType: "softmax" is a
+// direct linear readout, "mlp" first runs the standardized input through a
+// ReLU hidden layer.
+func computeScores(model LogisticModel, normFeats []float64) []float64 {
+	switch model.ModelType {
+	case "mlp":
+		hidden := make([]float64, len(model.HiddenWeights))
+		for h, w := range model.HiddenWeights {
+			dot := 0.0
+			for j, v := range normFeats {
+				dot += w[j] * v
+			}
+			dot += model.HiddenBias[h]
+			if dot < 0 {
+				dot = 0 // ReLU
+			}
+			hidden[h] = dot
+		}
 
-	for k := 0; k < len(model.ClassNames); k++ {
-		score := 0.0
-		for j, w := range model.Weights[k] {
-			score += w * normFeats[j]
+		scores := make([]float64, len(model.ClassNames))
+		for k := 0; k < len(model.ClassNames); k++ {
+			dot := 0.0
+			for h, v := range hidden {
+				dot += model.Weights[k][h] * v
+			}
+			scores[k] = dot + model.Bias[k]
 		}
-		score += model.Bias[k]
-		scores = append(scores, score)
-		if score > maxScore {
-			maxScore = score
+		return scores
+
+	default: // "softmax", or unset for older model artifacts
+		scores := make([]float64, len(model.ClassNames))
+		for k := 0; k < len(model.ClassNames); k++ {
+			dot := 0.0
+			for j, w := range model.Weights[k] {
+				dot += w * normFeats[j]
+			}
+			scores[k] = dot + model.Bias[k]
 		}
+		return scores
 	}
+}
 
-	var probs []float64
+// softmax computes class probabilities from the model's scores, dividing
+// logits by temperature before exponentiating (calibration).
+func softmax(model LogisticModel, normFeats []float64, temperature float64) []float64 {
+	scores := computeScores(model, normFeats)
+
+	maxScore := -1e9
+	for k := range scores {
+		scores[k] /= temperature
+		if scores[k] > maxScore {
+			maxScore = scores[k]
+		}
+	}
+
+	probs := make([]float64, len(scores))
 	sumExp := 0.0
-	for _, s := range scores {
+	for k, s := range scores {
 		p := math.Exp(s - maxScore)
-		probs = append(probs, p)
+		probs[k] = p
 		sumExp += p
 	}
+	for k := range probs {
+		probs[k] /= sumExp
+	}
+	return probs
+}
 
-	fmt.Println("Prediction for synthetic ISP-failure sample:")
-	for k, name := range model.ClassNames {
-		prob := probs[k] / sumExp
-		fmt.Printf("  %s: %.4f\n", name, prob)
+func argmax(probs []float64) int {
+	best, bestProb := 0, probs[0]
+	for k, p := range probs {
+		if p > bestProb {
+			best, bestProb = k, p
+		}
+	}
+	return best
+}
+
+// classify returns either the winning class name, or "unknown" plus a
+// human-readable reason when the top probability or top-2 margin falls
+// below the configured thresholds.
+func classify(classNames []string, probs []float64, abstainThreshold, marginThreshold float64) (string, string) {
+	first, second := rankTop2(probs)
+
+	if abstainThreshold > 0 && probs[first] < abstainThreshold {
+		return "unknown", fmt.Sprintf("top class %q confidence %.2f below abstain-threshold %.2f", classNames[first], probs[first], abstainThreshold)
+	}
+	if marginThreshold > 0 && probs[first]-probs[second] < marginThreshold {
+		return "unknown", fmt.Sprintf("%s vs %s ambiguous: %.2f vs %.2f", classNames[first], classNames[second], probs[first], probs[second])
+	}
+	return classNames[first], ""
+}
+
+// rankTop2 returns the indices of the highest and second-highest probability.
+func rankTop2(probs []float64) (first, second int) {
+	first, second = 0, 1
+	if probs[second] > probs[first] {
+		first, second = second, first
+	}
+	for k := 2; k < len(probs); k++ {
+		switch {
+		case probs[k] > probs[first]:
+			second = first
+			first = k
+		case probs[k] > probs[second]:
+			second = k
+		}
+	}
+	return first, second
+}
+
+// printTopK prints the K highest-probability classes, ranked.
+func printTopK(classNames []string, probs []float64, k int) {
+	type ranked struct {
+		name string
+		prob float64
+	}
+	all := make([]ranked, len(classNames))
+	for i, name := range classNames {
+		all[i] = ranked{name, probs[i]}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].prob > all[j].prob })
+
+	if k > len(all) {
+		k = len(all)
+	}
+	fmt.Printf("  top-%d:\n", k)
+	for i := 0; i < k; i++ {
+		fmt.Printf("    %d. %s: %.4f\n", i+1, all[i].name, all[i].prob)
+	}
+}
+
+// printExplanation reports each feature's standardized contribution
+// w[predicted][j] * normFeats[j] to the predicted class's score, ranked by
+// magnitude, so operators can see which metrics drove the verdict. This
+// only makes sense for the linear softmax backend: an mlp's hidden layer
+// mixes features before they reach the output weights.
+func printExplanation(model LogisticModel, normFeats []float64, predicted int) {
+	if model.ModelType == "mlp" {
+		fmt.Println("  explain: not supported for model_type=mlp (hidden layer mixes features before the output weights)")
+		return
+	}
+
+	type contribution struct {
+		feature string
+		value   float64
+	}
+	contributions := make([]contribution, len(normFeats))
+	for j, x := range normFeats {
+		contributions[j] = contribution{model.FeatureNames[j], model.Weights[predicted][j] * x}
+	}
+	sort.Slice(contributions, func(i, j int) bool {
+		return math.Abs(contributions[i].value) > math.Abs(contributions[j].value)
+	})
+
+	fmt.Printf("  explain (%s):\n", model.ClassNames[predicted])
+	for _, c := range contributions {
+		sign := "+"
+		if c.value < 0 {
+			sign = ""
+		}
+		fmt.Printf("    %s contributed %s%.2f to %s score\n", c.feature, sign, c.value, model.ClassNames[predicted])
+	}
+}
+
+// printModelQuality prints the CV-derived quality report embedded in the
+// model artifact, so operators can see how much to trust a prediction.
+func printModelQuality(m Metrics) {
+	fmt.Println()
+	fmt.Println("Model quality (from training-time cross-validation):")
+	fmt.Printf("  macro-F1: %.4f +/- %.4f\n", m.MacroF1Mean, m.MacroF1Stddev)
+	for _, cm := range m.PerClass {
+		fmt.Printf("  %-8s precision=%.3f recall=%.3f f1=%.3f\n", cm.Class, cm.Precision, cm.Recall, cm.F1)
 	}
+	fmt.Printf("  inference latency: min=%.4fms median=%.4fms p90=%.4fms p99=%.4fms\n",
+		m.LatencyMs.MinMs, m.LatencyMs.MedianMs, m.LatencyMs.P90Ms, m.LatencyMs.P99Ms)
 }
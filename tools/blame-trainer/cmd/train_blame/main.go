@@ -9,177 +9,195 @@ import (
 	"math/rand"
 	"os"
 	"time"
-)
-
-// Feature vector struct (Must align with Rust struct later)
-type Feature struct {
-	GwRttP50Ms    float64 `json:"gw_rtt_p50_ms"`
-	GwRttP95Ms    float64 `json:"gw_rtt_p95_ms"`
-	GwLossPct     float64 `json:"gw_loss_pct"`
-	WanRttP50Ms   float64 `json:"wan_rtt_p50_ms"`
-	WanRttP95Ms   float64 `json:"wan_rtt_p95_ms"`
-	WanLossPct    float64 `json:"wan_loss_pct"`
-	DeltaRttP50Ms float64 `json:"delta_rtt_p50_ms"` // Wan - Gw
-	DnsMsP50      float64 `json:"dns_ms_p50"`
-	DnsFailRate   float64 `json:"dns_fail_rate"`
-	HttpFailRate  float64 `json:"http_fail_rate"`
-	TcpFailRate   float64 `json:"tcp_fail_rate"`
-	WanDownMbps   float64 `json:"wan_down_mbps"`
-	WanUpMbps     float64 `json:"wan_up_mbps"`
-}
-
-// Convert Feature struct to Dense Vector for training
-func (f Feature) ToVector() []float64 {
-	return []float64{
-		f.GwRttP50Ms, f.GwRttP95Ms, f.GwLossPct,
-		f.WanRttP50Ms, f.WanRttP95Ms, f.WanLossPct,
-		f.DeltaRttP50Ms,
-		f.DnsMsP50, f.DnsFailRate,
-		f.HttpFailRate, f.TcpFailRate,
-		f.WanDownMbps, f.WanUpMbps,
-	}
-}
 
-// Class Labels
-const (
-	LabelWifi   = 0
-	LabelRouter = 1
-	LabelIsp    = 2
+	"github.com/ParkWardRR/PacketParamedic/tools/blame-trainer/internal/feature"
+	"github.com/ParkWardRR/PacketParamedic/tools/blame-trainer/internal/model"
+	"github.com/ParkWardRR/PacketParamedic/tools/blame-trainer/internal/stats"
 )
 
-var ClassNames = []string{"wifi", "router", "isp"}
-var FeatureNames = []string{
-	"gw_rtt_p50_ms", "gw_rtt_p95_ms", "gw_loss_pct",
-	"wan_rtt_p50_ms", "wan_rtt_p95_ms", "wan_loss_pct",
-	"delta_rtt_p50_ms",
-	"dns_ms_p50", "dns_fail_rate",
-	"http_fail_rate", "tcp_fail_rate",
-	"wan_down_mbps", "wan_up_mbps",
-}
-
-// Model Artifact Structure (Export JSON)
-type LogisticModel struct {
-	FeatureNames []string    `json:"feature_names"`
-	ClassNames   []string    `json:"class_names"`
-	Weights      [][]float64 `json:"weights"` // [n_classes][n_features]
-	Bias         []float64   `json:"bias"`    // [n_classes]
-	Means        []float64   `json:"means"`   // For standardization
-	Stds         []float64   `json:"stds"`    // For standardization
-}
+// Metrics and LogisticModel are the shared artifact types also used by
+// predict_blame; see internal/model.
+type (
+	Metrics       = model.Metrics
+	LogisticModel = model.LogisticModel
+)
 
 // Synthetic Data Generation
-func generateSample(class int) Feature {
+func generateSample(rng *rand.Rand, class int) feature.Feature {
 	// Helper for gaussian noise
-	rng := func(mean, std float64) float64 {
-		val := rand.NormFloat64()*std + mean
+	noise := func(mean, std float64) float64 {
+		val := rng.NormFloat64()*std + mean
 		if val < 0 {
 			return 0
 		}
 		return val
 	}
 
-	var f Feature
+	var f feature.Feature
 
 	// Base "good" values
 	wanBaseRtt := 15.0
 
 	switch class {
-	case LabelWifi:
+	case feature.LabelWifi:
 		// Wi-Fi: Gateway metrics degrade significantly.
-		gwRtt := rng(60.0, 10.0) // HIGH GW RTT
+		gwRtt := noise(60.0, 10.0) // HIGH GW RTT
 		f.GwRttP50Ms = gwRtt
-		f.GwRttP95Ms = gwRtt * rng(1.5, 0.2)
-		f.GwLossPct = rng(5.0, 2.0)
+		f.GwRttP95Ms = gwRtt * noise(1.5, 0.2)
+		f.GwLossPct = noise(5.0, 2.0)
 
 		// WAN is GW + Path, so WAN RTT is high
-		f.WanRttP50Ms = gwRtt + rng(wanBaseRtt, 2.0)
-		f.WanRttP95Ms = f.WanRttP50Ms * rng(1.2, 0.1)
-		f.WanLossPct = f.GwLossPct + rng(1.0, 0.5)
+		f.WanRttP50Ms = gwRtt + noise(wanBaseRtt, 2.0)
+		f.WanRttP95Ms = f.WanRttP50Ms * noise(1.2, 0.1)
+		f.WanLossPct = f.GwLossPct + noise(1.0, 0.5)
 
 		f.DeltaRttP50Ms = f.WanRttP50Ms - f.GwRttP50Ms // Should be ~15ms (normal path)
 
-		f.DnsMsP50 = rng(20.0, 5.0)
-		f.DnsFailRate = rng(0.01, 0.01)
-		f.HttpFailRate = rng(0.01, 0.01)
+		f.DnsMsP50 = noise(20.0, 5.0)
+		f.DnsFailRate = noise(0.01, 0.01)
+		f.HttpFailRate = noise(0.01, 0.01)
 
-	case LabelRouter:
+	case feature.LabelRouter:
 		// Router: GW bad but less than Wifi, LOSS is king here.
-		gwRtt := rng(25.0, 5.0)
+		gwRtt := noise(25.0, 5.0)
 		f.GwRttP50Ms = gwRtt
-		f.GwRttP95Ms = gwRtt * rng(2.0, 0.5)
-		f.GwLossPct = rng(15.0, 5.0) // HIGH LOSS
+		f.GwRttP95Ms = gwRtt * noise(2.0, 0.5)
+		f.GwLossPct = noise(15.0, 5.0) // HIGH LOSS
 
-		f.WanRttP50Ms = gwRtt + rng(wanBaseRtt, 5.0)
-		f.WanLossPct = f.GwLossPct + rng(2.0, 1.0)
+		f.WanRttP50Ms = gwRtt + noise(wanBaseRtt, 5.0)
+		f.WanLossPct = f.GwLossPct + noise(2.0, 1.0)
 		f.DeltaRttP50Ms = f.WanRttP50Ms - f.GwRttP50Ms
 
-		f.DnsMsP50 = rng(100.0, 50.0)
-		f.DnsFailRate = rng(0.25, 0.1) // High failure
-		f.HttpFailRate = rng(0.20, 0.1)
-		f.TcpFailRate = rng(0.15, 0.1)
+		f.DnsMsP50 = noise(100.0, 50.0)
+		f.DnsFailRate = noise(0.25, 0.1) // High failure
+		f.HttpFailRate = noise(0.20, 0.1)
+		f.TcpFailRate = noise(0.15, 0.1)
 
-	case LabelIsp:
+	case feature.LabelIsp:
 		// ISP: Gateway is pristine. WAN metrics degrade. Delta RTT is huge.
-		f.GwRttP50Ms = rng(2.0, 0.5) // PRISTINE GW
-		f.GwRttP95Ms = rng(3.0, 1.0)
-		f.GwLossPct = rng(0.0, 0.1)
+		f.GwRttP50Ms = noise(2.0, 0.5) // PRISTINE GW
+		f.GwRttP95Ms = noise(3.0, 1.0)
+		f.GwLossPct = noise(0.0, 0.1)
 
-		f.WanRttP50Ms = rng(120.0, 30.0) // HIGH LATENCY UPSTREAM
-		f.WanRttP95Ms = f.WanRttP50Ms * rng(1.5, 0.2)
-		f.WanLossPct = rng(5.0, 2.0)
+		f.WanRttP50Ms = noise(120.0, 30.0) // HIGH LATENCY UPSTREAM
+		f.WanRttP95Ms = f.WanRttP50Ms * noise(1.5, 0.2)
+		f.WanLossPct = noise(5.0, 2.0)
 
 		f.DeltaRttP50Ms = f.WanRttP50Ms - f.GwRttP50Ms // HUGE DELTA
 
-		f.DnsMsP50 = rng(50.0, 10.0)
-		f.DnsFailRate = rng(0.05, 0.02)
-		f.HttpFailRate = rng(0.05, 0.02)
+		f.DnsMsP50 = noise(50.0, 10.0)
+		f.DnsFailRate = noise(0.05, 0.02)
+		f.HttpFailRate = noise(0.05, 0.02)
 	}
 
 	return f
 }
 
-// simple Softmax Regression training (SGD)
-func trainSoftmax(features [][]float64, labels []int, epochs int, lr float64) (*LogisticModel, float64) {
-	nSamples := len(features)
+// fittedSoftmax holds the parameters learned by fitSoftmax: standardization
+// stats plus the linear weights/bias, all derived only from the given
+// training indices (no leakage from held-out samples).
+type fittedSoftmax struct {
+	weights [][]float64
+	bias    []float64
+	means   []float64
+	stds    []float64
+}
+
+// TrainConfig bundles the optimizer knobs for fitSoftmax: mini-batch SGD
+// with L2 weight decay, learning-rate decay, and early stopping against an
+// internal validation split carved out of the training indices.
+type TrainConfig struct {
+	Epochs       int
+	LR           float64
+	L2           float64
+	BatchSize    int
+	LRDecay      string // "", "exponential", or "inv-sqrt"
+	Patience     int
+	EarlyStopVal float64 // fraction of trainIdx held out for early-stopping loss
+}
+
+// decayedLR applies the configured learning-rate schedule for the given
+// (0-indexed) epoch.
+func (c TrainConfig) decayedLR(epoch int) float64 {
+	switch c.LRDecay {
+	case "exponential":
+		return c.LR * math.Exp(-0.01*float64(epoch))
+	case "inv-sqrt":
+		return c.LR / math.Sqrt(float64(epoch)+1)
+	default:
+		return c.LR
+	}
+}
+
+// earlyStopSplit shuffles trainIdx and carves off valFrac of it (at least
+// one sample, and never all of it) as an early-stopping validation set,
+// returning (valIdx, optIdx). trainIdx must have at least 2 samples, since
+// a single-sample fold can't be split into both a training set and a
+// validation set; callers reach this through -folds/-holdout, which main()
+// validates, but fitSoftmax/fitMLP also guard here directly since they're
+// called per-CV-fold.
+func earlyStopSplit(rng *rand.Rand, trainIdx []int, valFrac float64) (valIdx, optIdx []int) {
+	if len(trainIdx) < 2 {
+		log.Fatalf("fold has only %d training sample(s); need at least 2 to carve an early-stopping split (check -folds/-holdout/-n)", len(trainIdx))
+	}
+
+	shuffled := make([]int, len(trainIdx))
+	copy(shuffled, trainIdx)
+	rng.Shuffle(len(shuffled), func(a, b int) { shuffled[a], shuffled[b] = shuffled[b], shuffled[a] })
+
+	nVal := int(float64(len(shuffled)) * valFrac)
+	if nVal < 1 {
+		nVal = 1
+	}
+	if nVal > len(shuffled)-1 {
+		nVal = len(shuffled) - 1
+	}
+
+	return shuffled[:nVal], shuffled[nVal:]
+}
+
+// fitSoftmax trains a softmax regression restricted to trainIdx via
+// mini-batch SGD with L2 weight decay, standardizing on that subset only.
+// A slice of trainIdx is reserved for early-stopping: training aborts once
+// that held-out loss hasn't improved for cfg.Patience epochs, and the best
+// weights seen are restored.
+func fitSoftmax(rng *rand.Rand, features [][]float64, labels []int, trainIdx []int, nClasses int, cfg TrainConfig) *fittedSoftmax {
 	nFeats := len(features[0])
-	nClasses := 3
 
-	// 1. Compute standardization parameters
 	means := make([]float64, nFeats)
 	stds := make([]float64, nFeats)
 
-	for _, samp := range features {
-		for j, val := range samp {
+	for _, i := range trainIdx {
+		for j, val := range features[i] {
 			means[j] += val
 		}
 	}
 	for j := 0; j < nFeats; j++ {
-		means[j] /= float64(nSamples)
+		means[j] /= float64(len(trainIdx))
 	}
 
-	for _, samp := range features {
-		for j, val := range samp {
+	for _, i := range trainIdx {
+		for j, val := range features[i] {
 			stds[j] += math.Pow(val-means[j], 2)
 		}
 	}
 	for j := 0; j < nFeats; j++ {
-		stds[j] = math.Sqrt(stds[j] / float64(nSamples))
+		stds[j] = math.Sqrt(stds[j] / float64(len(trainIdx)))
 		if stds[j] < 1e-6 {
 			stds[j] = 1.0
 		} // Prevent div/0
 	}
 
-	// 2. Standardize features
-	normFeatures := make([][]float64, nSamples)
-	for i, samp := range features {
+	normFeatures := make(map[int][]float64, len(trainIdx))
+	for _, i := range trainIdx {
 		norm := make([]float64, nFeats)
-		for j, val := range samp {
+		for j, val := range features[i] {
 			norm[j] = (val - means[j]) / stds[j]
 		}
 		normFeatures[i] = norm
 	}
 
-	// 3. Initialize weights (Xavier/GLOROT)
+	// Initialize weights (Xavier/GLOROT)
 	weights := make([][]float64, nClasses)
 	bias := make([]float64, nClasses)
 	limit := math.Sqrt(6.0 / float64(nFeats+nClasses))
@@ -187,19 +205,21 @@ func trainSoftmax(features [][]float64, labels []int, epochs int, lr float64) (*
 	for k := 0; k < nClasses; k++ {
 		weights[k] = make([]float64, nFeats)
 		for j := 0; j < nFeats; j++ {
-			weights[k][j] = rand.Float64()*2*limit - limit
+			weights[k][j] = rng.Float64()*2*limit - limit
 		}
 	}
 
-	// 4. Training Loop (SGD)
-	for epoch := 0; epoch < epochs; epoch++ {
-		// Shuffle (omitted for brevity, assume random enough order)
+	// Carve an early-stopping validation slice out of trainIdx. The
+	// optimizer only ever sees optIdx; valIdx exists purely to decide when
+	// to stop.
+	valIdx, optIdx := earlyStopSplit(rng, trainIdx, cfg.EarlyStopVal)
 
-		for i := 0; i < nSamples; i++ {
+	lossFn := func(idx []int) float64 {
+		ce := 0.0
+		for _, i := range idx {
 			x := normFeatures[i]
 			y := labels[i]
 
-			// Forward pass: Scores z_k = w_k * x + b_k
 			scores := make([]float64, nClasses)
 			maxScore := -1e9
 			for k := 0; k < nClasses; k++ {
@@ -212,75 +232,623 @@ func trainSoftmax(features [][]float64, labels []int, epochs int, lr float64) (*
 					maxScore = scores[k]
 				}
 			}
-
-			// Softmax
 			sumExp := 0.0
-			probs := make([]float64, nClasses)
 			for k := 0; k < nClasses; k++ {
-				probs[k] = math.Exp(scores[k] - maxScore) // Stable softmax
-				sumExp += probs[k]
+				sumExp += math.Exp(scores[k] - maxScore)
 			}
-			for k := 0; k < nClasses; k++ {
-				probs[k] /= sumExp
+			ce += -(scores[y] - maxScore - math.Log(sumExp))
+		}
+		ce /= float64(len(idx))
+
+		l2Term := 0.0
+		for k := 0; k < nClasses; k++ {
+			for j := 0; j < nFeats; j++ {
+				l2Term += weights[k][j] * weights[k][j]
 			}
+		}
+		return ce + cfg.L2*l2Term
+	}
 
-			// Backward pass
-			// grad_z_k = p_k - (1 if k==y else 0)
-			for k := 0; k < nClasses; k++ {
-				grad := probs[k]
-				if k == y {
-					grad -= 1.0
+	cloneWeights := func() ([][]float64, []float64) {
+		w := make([][]float64, nClasses)
+		for k := range weights {
+			w[k] = append([]float64(nil), weights[k]...)
+		}
+		b := append([]float64(nil), bias...)
+		return w, b
+	}
+
+	bestWeights, bestBias := cloneWeights()
+	bestValLoss := math.Inf(1)
+	epochsSinceImprovement := 0
+
+	batchSize := cfg.BatchSize
+	if batchSize < 1 {
+		batchSize = len(optIdx)
+	}
+
+	for epoch := 0; epoch < cfg.Epochs; epoch++ {
+		rng.Shuffle(len(optIdx), func(a, b int) { optIdx[a], optIdx[b] = optIdx[b], optIdx[a] })
+		lr := cfg.decayedLR(epoch)
+
+		for start := 0; start < len(optIdx); start += batchSize {
+			end := start + batchSize
+			if end > len(optIdx) {
+				end = len(optIdx)
+			}
+			batch := optIdx[start:end]
+
+			gradW := make([][]float64, nClasses)
+			gradB := make([]float64, nClasses)
+			for k := range gradW {
+				gradW[k] = make([]float64, nFeats)
+			}
+
+			for _, i := range batch {
+				x := normFeatures[i]
+				y := labels[i]
+
+				scores := make([]float64, nClasses)
+				maxScore := -1e9
+				for k := 0; k < nClasses; k++ {
+					dot := 0.0
+					for j := 0; j < nFeats; j++ {
+						dot += weights[k][j] * x[j]
+					}
+					scores[k] = dot + bias[k]
+					if scores[k] > maxScore {
+						maxScore = scores[k]
+					}
 				}
 
-				// Update bias
-				bias[k] -= lr * grad
+				sumExp := 0.0
+				probs := make([]float64, nClasses)
+				for k := 0; k < nClasses; k++ {
+					probs[k] = math.Exp(scores[k] - maxScore) // Stable softmax
+					sumExp += probs[k]
+				}
+				for k := 0; k < nClasses; k++ {
+					probs[k] /= sumExp
+				}
 
-				// Update weights
+				// grad_z_k = p_k - (1 if k==y else 0)
+				for k := 0; k < nClasses; k++ {
+					grad := probs[k]
+					if k == y {
+						grad -= 1.0
+					}
+
+					gradB[k] += grad
+					for j := 0; j < nFeats; j++ {
+						gradW[k][j] += grad * x[j]
+					}
+				}
+			}
+
+			n := float64(len(batch))
+			for k := 0; k < nClasses; k++ {
+				bias[k] -= lr * gradB[k] / n
 				for j := 0; j < nFeats; j++ {
-					weights[k][j] -= lr * grad * x[j]
+					// Average data gradient plus L2 weight-decay term (d/dw of lambda*||W||^2).
+					weights[k][j] -= lr * (gradW[k][j]/n + 2*cfg.L2*weights[k][j])
 				}
 			}
 		}
+
+		valLoss := lossFn(valIdx)
+		log.Printf("  epoch %d/%d: lr=%.5f val_loss=%.5f (best=%.5f)", epoch+1, cfg.Epochs, lr, valLoss, bestValLoss)
+
+		if valLoss < bestValLoss {
+			bestValLoss = valLoss
+			bestWeights, bestBias = cloneWeights()
+			epochsSinceImprovement = 0
+		} else {
+			epochsSinceImprovement++
+			if cfg.Patience > 0 && epochsSinceImprovement >= cfg.Patience {
+				log.Printf("  early stopping at epoch %d (no improvement for %d epochs)", epoch+1, cfg.Patience)
+				break
+			}
+		}
 	}
 
-	// Calculate final accuracy
-	correct := 0
-	for i := 0; i < nSamples; i++ {
-		x := normFeatures[i]
-		y := labels[i]
+	return &fittedSoftmax{weights: bestWeights, bias: bestBias, means: means, stds: stds}
+}
 
-		scores := make([]float64, nClasses)
-		for k := 0; k < nClasses; k++ {
+// predict returns class probabilities and the per-sample inference latency
+// in milliseconds for a single feature vector.
+func (m *fittedSoftmax) predict(x []float64) (probs []float64, latencyMs float64) {
+	start := time.Now()
+
+	nClasses := len(m.weights)
+	norm := make([]float64, len(x))
+	for j, val := range x {
+		norm[j] = (val - m.means[j]) / m.stds[j]
+	}
+
+	scores := make([]float64, nClasses)
+	maxScore := -1e9
+	for k := 0; k < nClasses; k++ {
+		dot := 0.0
+		for j, v := range norm {
+			dot += m.weights[k][j] * v
+		}
+		scores[k] = dot + m.bias[k]
+		if scores[k] > maxScore {
+			maxScore = scores[k]
+		}
+	}
+
+	sumExp := 0.0
+	probs = make([]float64, nClasses)
+	for k := 0; k < nClasses; k++ {
+		probs[k] = math.Exp(scores[k] - maxScore)
+		sumExp += probs[k]
+	}
+	for k := 0; k < nClasses; k++ {
+		probs[k] /= sumExp
+	}
+
+	return probs, float64(time.Since(start).Microseconds()) / 1000.0
+}
+
+// ModelParams is the flat, JSON-artifact-shaped view of a fittedModel's
+// learned parameters, used to populate LogisticModel regardless of which
+// backend produced them.
+type ModelParams struct {
+	ModelType     string
+	Weights       [][]float64
+	Bias          []float64
+	HiddenWeights [][]float64
+	HiddenBias    []float64
+	Means         []float64
+	Stds          []float64
+}
+
+// fittedModel is anything that can score a standardized-on-its-own feature
+// vector and report its learned parameters for export. fittedSoftmax and
+// fittedMLP both satisfy it, so crossValidate/main don't need to care which
+// backend -model-type selected.
+type fittedModel interface {
+	predict(x []float64) (probs []float64, latencyMs float64)
+	export() ModelParams
+}
+
+func (m *fittedSoftmax) export() ModelParams {
+	return ModelParams{
+		ModelType: "softmax",
+		Weights:   m.weights,
+		Bias:      m.bias,
+		Means:     m.means,
+		Stds:      m.stds,
+	}
+}
+
+// fittedMLP is a one-hidden-layer ReLU network: hidden = ReLU(W1 x + b1),
+// scores = W2 hidden + b2. It exists because the wifi/router/isp boundary
+// mixes several conditions (moderate GW RTT AND high loss AND high DNS
+// failure, for router) in a way a single linear boundary blurs together.
+type fittedMLP struct {
+	w1, w2 [][]float64
+	b1, b2 []float64
+	means  []float64
+	stds   []float64
+}
+
+func (m *fittedMLP) export() ModelParams {
+	return ModelParams{
+		ModelType:     "mlp",
+		Weights:       m.w2,
+		Bias:          m.b2,
+		HiddenWeights: m.w1,
+		HiddenBias:    m.b1,
+		Means:         m.means,
+		Stds:          m.stds,
+	}
+}
+
+func relu(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	return x
+}
+
+func reluDeriv(x float64) float64 {
+	if x < 0 {
+		return 0
+	}
+	return 1
+}
+
+// forward runs the network on an already-standardized feature vector,
+// returning the hidden activations (post-ReLU) and the output scores.
+func (m *fittedMLP) forward(norm []float64) (hidden, scores []float64) {
+	hidden = make([]float64, len(m.w1))
+	for h := range m.w1 {
+		dot := 0.0
+		for j, v := range norm {
+			dot += m.w1[h][j] * v
+		}
+		hidden[h] = relu(dot + m.b1[h])
+	}
+
+	scores = make([]float64, len(m.w2))
+	for k := range m.w2 {
+		dot := 0.0
+		for h, v := range hidden {
+			dot += m.w2[k][h] * v
+		}
+		scores[k] = dot + m.b2[k]
+	}
+	return hidden, scores
+}
+
+func (m *fittedMLP) predict(x []float64) (probs []float64, latencyMs float64) {
+	start := time.Now()
+
+	norm := make([]float64, len(x))
+	for j, val := range x {
+		norm[j] = (val - m.means[j]) / m.stds[j]
+	}
+
+	_, scores := m.forward(norm)
+
+	maxScore := -1e9
+	for _, s := range scores {
+		if s > maxScore {
+			maxScore = s
+		}
+	}
+	sumExp := 0.0
+	probs = make([]float64, len(scores))
+	for k, s := range scores {
+		probs[k] = math.Exp(s - maxScore)
+		sumExp += probs[k]
+	}
+	for k := range probs {
+		probs[k] /= sumExp
+	}
+
+	return probs, float64(time.Since(start).Microseconds()) / 1000.0
+}
+
+// fitMLP trains a one-hidden-layer ReLU MLP restricted to trainIdx, reusing
+// the same mini-batch SGD, L2 decay, LR schedule, and early-stopping
+// machinery as fitSoftmax.
+func fitMLP(rng *rand.Rand, features [][]float64, labels []int, trainIdx []int, nClasses, hiddenSize int, cfg TrainConfig) *fittedMLP {
+	nFeats := len(features[0])
+
+	means := make([]float64, nFeats)
+	stds := make([]float64, nFeats)
+	for _, i := range trainIdx {
+		for j, val := range features[i] {
+			means[j] += val
+		}
+	}
+	for j := 0; j < nFeats; j++ {
+		means[j] /= float64(len(trainIdx))
+	}
+	for _, i := range trainIdx {
+		for j, val := range features[i] {
+			stds[j] += math.Pow(val-means[j], 2)
+		}
+	}
+	for j := 0; j < nFeats; j++ {
+		stds[j] = math.Sqrt(stds[j] / float64(len(trainIdx)))
+		if stds[j] < 1e-6 {
+			stds[j] = 1.0
+		}
+	}
+
+	normFeatures := make(map[int][]float64, len(trainIdx))
+	for _, i := range trainIdx {
+		norm := make([]float64, nFeats)
+		for j, val := range features[i] {
+			norm[j] = (val - means[j]) / stds[j]
+		}
+		normFeatures[i] = norm
+	}
+
+	// He initialization for the ReLU hidden layer.
+	heLimit := math.Sqrt(2.0 / float64(nFeats))
+	w1 := make([][]float64, hiddenSize)
+	b1 := make([]float64, hiddenSize)
+	for h := 0; h < hiddenSize; h++ {
+		w1[h] = make([]float64, nFeats)
+		for j := 0; j < nFeats; j++ {
+			w1[h][j] = rng.NormFloat64() * heLimit
+		}
+	}
+
+	// Glorot initialization for the softmax output layer, matching fitSoftmax.
+	outLimit := math.Sqrt(6.0 / float64(hiddenSize+nClasses))
+	w2 := make([][]float64, nClasses)
+	b2 := make([]float64, nClasses)
+	for k := 0; k < nClasses; k++ {
+		w2[k] = make([]float64, hiddenSize)
+		for h := 0; h < hiddenSize; h++ {
+			w2[k][h] = rng.Float64()*2*outLimit - outLimit
+		}
+	}
+
+	valIdx, optIdx := earlyStopSplit(rng, trainIdx, cfg.EarlyStopVal)
+
+	forward := func(x []float64) (hiddenPre, hidden, scores []float64) {
+		hiddenPre = make([]float64, hiddenSize)
+		hidden = make([]float64, hiddenSize)
+		for h := 0; h < hiddenSize; h++ {
 			dot := 0.0
 			for j := 0; j < nFeats; j++ {
-				dot += weights[k][j] * x[j]
+				dot += w1[h][j] * x[j]
 			}
-			scores[k] = dot + bias[k]
+			hiddenPre[h] = dot + b1[h]
+			hidden[h] = relu(hiddenPre[h])
 		}
 
-		bestK := -1
-		bestScore := -1e9
-		for k, s := range scores {
-			if s > bestScore {
-				bestScore = s
-				bestK = k
+		scores = make([]float64, nClasses)
+		for k := 0; k < nClasses; k++ {
+			dot := 0.0
+			for h := 0; h < hiddenSize; h++ {
+				dot += w2[k][h] * hidden[h]
 			}
+			scores[k] = dot + b2[k]
 		}
-		if bestK == y {
-			correct++
+		return hiddenPre, hidden, scores
+	}
+
+	lossFn := func(idx []int) float64 {
+		ce := 0.0
+		for _, i := range idx {
+			_, _, scores := forward(normFeatures[i])
+			y := labels[i]
+
+			maxScore := -1e9
+			for _, s := range scores {
+				if s > maxScore {
+					maxScore = s
+				}
+			}
+			sumExp := 0.0
+			for _, s := range scores {
+				sumExp += math.Exp(s - maxScore)
+			}
+			ce += -(scores[y] - maxScore - math.Log(sumExp))
 		}
+		ce /= float64(len(idx))
+
+		l2Term := 0.0
+		for k := range w2 {
+			for h := range w2[k] {
+				l2Term += w2[k][h] * w2[k][h]
+			}
+		}
+		for h := range w1 {
+			for j := range w1[h] {
+				l2Term += w1[h][j] * w1[h][j]
+			}
+		}
+		return ce + cfg.L2*l2Term
 	}
 
-	accuracy := float64(correct) / float64(nSamples)
+	cloneParams := func() ([][]float64, []float64, [][]float64, []float64) {
+		cw1 := make([][]float64, len(w1))
+		for h := range w1 {
+			cw1[h] = append([]float64(nil), w1[h]...)
+		}
+		cw2 := make([][]float64, len(w2))
+		for k := range w2 {
+			cw2[k] = append([]float64(nil), w2[k]...)
+		}
+		return cw1, append([]float64(nil), b1...), cw2, append([]float64(nil), b2...)
+	}
 
-	return &LogisticModel{
-		FeatureNames: FeatureNames,
-		ClassNames:   ClassNames,
-		Weights:      weights,
-		Bias:         bias,
-		Means:        means,
-		Stds:         stds,
-	}, accuracy
+	bestW1, bestB1, bestW2, bestB2 := cloneParams()
+	bestValLoss := math.Inf(1)
+	epochsSinceImprovement := 0
+
+	batchSize := cfg.BatchSize
+	if batchSize < 1 {
+		batchSize = len(optIdx)
+	}
+
+	for epoch := 0; epoch < cfg.Epochs; epoch++ {
+		rng.Shuffle(len(optIdx), func(a, b int) { optIdx[a], optIdx[b] = optIdx[b], optIdx[a] })
+		lr := cfg.decayedLR(epoch)
+
+		for start := 0; start < len(optIdx); start += batchSize {
+			end := start + batchSize
+			if end > len(optIdx) {
+				end = len(optIdx)
+			}
+			batch := optIdx[start:end]
+
+			gradW1 := make([][]float64, hiddenSize)
+			gradB1 := make([]float64, hiddenSize)
+			for h := range gradW1 {
+				gradW1[h] = make([]float64, nFeats)
+			}
+			gradW2 := make([][]float64, nClasses)
+			gradB2 := make([]float64, nClasses)
+			for k := range gradW2 {
+				gradW2[k] = make([]float64, hiddenSize)
+			}
+
+			for _, i := range batch {
+				x := normFeatures[i]
+				y := labels[i]
+				hiddenPre, hidden, scores := forward(x)
+
+				maxScore := -1e9
+				for _, s := range scores {
+					if s > maxScore {
+						maxScore = s
+					}
+				}
+				sumExp := 0.0
+				probs := make([]float64, nClasses)
+				for k := 0; k < nClasses; k++ {
+					probs[k] = math.Exp(scores[k] - maxScore)
+					sumExp += probs[k]
+				}
+				for k := 0; k < nClasses; k++ {
+					probs[k] /= sumExp
+				}
+
+				// Output layer gradient: dL/dscore_k = p_k - 1{k==y}.
+				gradScore := make([]float64, nClasses)
+				for k := 0; k < nClasses; k++ {
+					gradScore[k] = probs[k]
+					if k == y {
+						gradScore[k] -= 1.0
+					}
+					gradB2[k] += gradScore[k]
+					for h := 0; h < hiddenSize; h++ {
+						gradW2[k][h] += gradScore[k] * hidden[h]
+					}
+				}
+
+				// Backprop through the hidden ReLU layer.
+				for h := 0; h < hiddenSize; h++ {
+					dHidden := 0.0
+					for k := 0; k < nClasses; k++ {
+						dHidden += gradScore[k] * w2[k][h]
+					}
+					dPre := dHidden * reluDeriv(hiddenPre[h])
+					gradB1[h] += dPre
+					for j := 0; j < nFeats; j++ {
+						gradW1[h][j] += dPre * x[j]
+					}
+				}
+			}
+
+			n := float64(len(batch))
+			for k := 0; k < nClasses; k++ {
+				b2[k] -= lr * gradB2[k] / n
+				for h := 0; h < hiddenSize; h++ {
+					w2[k][h] -= lr * (gradW2[k][h]/n + 2*cfg.L2*w2[k][h])
+				}
+			}
+			for h := 0; h < hiddenSize; h++ {
+				b1[h] -= lr * gradB1[h] / n
+				for j := 0; j < nFeats; j++ {
+					w1[h][j] -= lr * (gradW1[h][j]/n + 2*cfg.L2*w1[h][j])
+				}
+			}
+		}
+
+		valLoss := lossFn(valIdx)
+		log.Printf("  epoch %d/%d: lr=%.5f val_loss=%.5f (best=%.5f)", epoch+1, cfg.Epochs, lr, valLoss, bestValLoss)
+
+		if valLoss < bestValLoss {
+			bestValLoss = valLoss
+			bestW1, bestB1, bestW2, bestB2 = cloneParams()
+			epochsSinceImprovement = 0
+		} else {
+			epochsSinceImprovement++
+			if cfg.Patience > 0 && epochsSinceImprovement >= cfg.Patience {
+				log.Printf("  early stopping at epoch %d (no improvement for %d epochs)", epoch+1, cfg.Patience)
+				break
+			}
+		}
+	}
+
+	return &fittedMLP{w1: bestW1, w2: bestW2, b1: bestB1, b2: bestB2, means: means, stds: stds}
+}
+
+func argmax(probs []float64) int {
+	best, bestProb := 0, probs[0]
+	for k, p := range probs {
+		if p > bestProb {
+			best, bestProb = k, p
+		}
+	}
+	return best
+}
+
+// stratifiedFolds partitions sample indices into `folds` groups, keeping
+// each class's proportion roughly constant per fold.
+func stratifiedFolds(rng *rand.Rand, labels []int, idx []int, nClasses, folds int) [][]int {
+	byClass := make([][]int, nClasses)
+	for _, i := range idx {
+		byClass[labels[i]] = append(byClass[labels[i]], i)
+	}
+	for k := range byClass {
+		rng.Shuffle(len(byClass[k]), func(a, b int) { byClass[k][a], byClass[k][b] = byClass[k][b], byClass[k][a] })
+	}
+
+	foldIdx := make([][]int, folds)
+	for k := range byClass {
+		for i, sample := range byClass[k] {
+			f := i % folds
+			foldIdx[f] = append(foldIdx[f], sample)
+		}
+	}
+	return foldIdx
+}
+
+// trainFn fits a fittedModel over trainIdx; it closes over whichever
+// backend (-model-type) and hyperparameters main() selected, so
+// crossValidate and the final fit share one code path regardless of
+// backend.
+type trainFn func(rng *rand.Rand, features [][]float64, labels []int, trainIdx []int, nClasses int) fittedModel
+
+// crossValidate runs stratified k-fold CV over pool and returns the
+// aggregated Metrics, whose macro-F1 mean/stddev are taken over the
+// per-fold macro-F1 scores.
+func crossValidate(rng *rand.Rand, features [][]float64, labels []int, pool []int, nClasses, folds int, train trainFn) Metrics {
+	foldIdx := stratifiedFolds(rng, labels, pool, nClasses, folds)
+
+	combinedCM := stats.NewConfusionMatrix(nClasses)
+	var macroF1s []float64
+	var latencies []float64
+
+	for f := 0; f < folds; f++ {
+		valIdx := foldIdx[f]
+		var trainIdx []int
+		for other := 0; other < folds; other++ {
+			if other != f {
+				trainIdx = append(trainIdx, foldIdx[other]...)
+			}
+		}
+
+		fitted := train(rng, features, labels, trainIdx, nClasses)
+
+		foldCM := stats.NewConfusionMatrix(nClasses)
+		for _, i := range valIdx {
+			probs, latencyMs := fitted.predict(features[i])
+			foldCM.Add(labels[i], argmax(probs))
+			latencies = append(latencies, latencyMs)
+		}
+
+		foldPerClass := stats.PerClassMetrics(foldCM, feature.ClassNames)
+		macroF1s = append(macroF1s, stats.MacroF1(foldPerClass))
+		combinedCM.Merge(foldCM)
+	}
+
+	perClass := stats.PerClassMetrics(combinedCM, feature.ClassNames)
+
+	mean := 0.0
+	for _, v := range macroF1s {
+		mean += v
+	}
+	mean /= float64(len(macroF1s))
+
+	variance := 0.0
+	for _, v := range macroF1s {
+		variance += math.Pow(v-mean, 2)
+	}
+	stddev := math.Sqrt(variance / float64(len(macroF1s)))
+
+	cmOut := make([][]int, nClasses)
+	copy(cmOut, combinedCM)
+
+	return Metrics{
+		PerClass:        perClass,
+		MacroF1Mean:     mean,
+		MacroF1Stddev:   stddev,
+		ConfusionMatrix: cmOut,
+		LatencyMs:       stats.SummarizeLatency(latencies),
+	}
 }
 
 func main() {
@@ -288,9 +856,51 @@ func main() {
 	outPath := flag.String("out", "./blame_lr.json", "Output JSON path")
 	epochs := flag.Int("epochs", 100, "Training epochs")
 	lr := flag.Float64("lr", 0.01, "Learning rate")
+	l2 := flag.Float64("l2", 1e-4, "L2 weight decay coefficient")
+	batch := flag.Int("batch", 64, "Mini-batch size for SGD")
+	lrDecay := flag.String("lr-decay", "", "Learning-rate decay schedule: \"\", \"exponential\", or \"inv-sqrt\"")
+	patience := flag.Int("patience", 5, "Stop training after this many epochs without validation-loss improvement (0 disables early stopping)")
+	modelType := flag.String("model-type", "softmax", "Model backend: \"softmax\" or \"mlp\"")
+	hidden := flag.Int("hidden", 16, "Hidden layer size, -model-type=mlp only")
+	report := flag.Bool("report", false, "After training, print a feature-importance report (permutation + weight-norm) and write -importance-out")
+	importanceOut := flag.String("importance-out", "./importance.json", "Path for the -report feature-importance JSON")
+	folds := flag.Int("folds", 5, "Number of stratified CV folds")
+	holdout := flag.Float64("holdout", 0.1, "Fraction of samples held out entirely from CV/training, for a final sanity check")
+	seed := flag.Int64("seed", 42, "Random seed for data generation, fold assignment, and weight init")
 	flag.Parse()
 
-	rand.Seed(time.Now().UnixNano())
+	if *folds < 2 {
+		log.Fatalf("-folds must be at least 2 (each fold needs the rest of the pool as its training set), got %d", *folds)
+	}
+	if *holdout < 0 || *holdout >= 1 {
+		log.Fatalf("-holdout must be in [0, 1) so the CV pool isn't empty, got %v", *holdout)
+	}
+
+	rng := rand.New(rand.NewSource(*seed))
+
+	cfg := TrainConfig{
+		Epochs:       *epochs,
+		LR:           *lr,
+		L2:           *l2,
+		BatchSize:    *batch,
+		LRDecay:      *lrDecay,
+		Patience:     *patience,
+		EarlyStopVal: 0.1,
+	}
+
+	var train trainFn
+	switch *modelType {
+	case "mlp":
+		train = func(rng *rand.Rand, features [][]float64, labels []int, trainIdx []int, nClasses int) fittedModel {
+			return fitMLP(rng, features, labels, trainIdx, nClasses, *hidden, cfg)
+		}
+	case "softmax":
+		train = func(rng *rand.Rand, features [][]float64, labels []int, trainIdx []int, nClasses int) fittedModel {
+			return fitSoftmax(rng, features, labels, trainIdx, nClasses, cfg)
+		}
+	default:
+		log.Fatalf("unknown -model-type %q (want \"softmax\" or \"mlp\")", *modelType)
+	}
 
 	log.Printf("Generating %d synthetic samples...", *nSamples)
 
@@ -299,16 +909,60 @@ func main() {
 
 	// Balanced dataset
 	for i := 0; i < *nSamples; i++ {
-		label := rand.Intn(3)
-		sample := generateSample(label)
+		label := rng.Intn(3)
+		sample := generateSample(rng, label)
 		features = append(features, sample.ToVector())
 		labels = append(labels, label)
 	}
 
-	log.Println("Training Softmax Regression...")
-	model, acc := trainSoftmax(features, labels, *epochs, *lr)
+	nClasses := 3
+	allIdx := make([]int, len(features))
+	for i := range allIdx {
+		allIdx[i] = i
+	}
+	rng.Shuffle(len(allIdx), func(a, b int) { allIdx[a], allIdx[b] = allIdx[b], allIdx[a] })
+
+	nHoldout := int(float64(len(allIdx)) * *holdout)
+	holdoutIdx := allIdx[:nHoldout]
+	cvPoolIdx := allIdx[nHoldout:]
+
+	log.Printf("Running %d-fold stratified CV (%s) over %d training-pool samples (%d held out)...", *folds, *modelType, len(cvPoolIdx), len(holdoutIdx))
+	metrics := crossValidate(rng, features, labels, cvPoolIdx, nClasses, *folds, train)
+
+	log.Printf("CV macro-F1: %.4f +/- %.4f", metrics.MacroF1Mean, metrics.MacroF1Stddev)
+	for _, cm := range metrics.PerClass {
+		log.Printf("  %-8s precision=%.3f recall=%.3f f1=%.3f", cm.Class, cm.Precision, cm.Recall, cm.F1)
+	}
+	log.Printf("Inference latency: min=%.4fms median=%.4fms p90=%.4fms p99=%.4fms",
+		metrics.LatencyMs.MinMs, metrics.LatencyMs.MedianMs, metrics.LatencyMs.P90Ms, metrics.LatencyMs.P99Ms)
+
+	log.Println("Training final model on full CV pool...")
+	final := train(rng, features, labels, cvPoolIdx, nClasses)
+
+	if len(holdoutIdx) > 0 {
+		correct := 0
+		for _, i := range holdoutIdx {
+			probs, _ := final.predict(features[i])
+			if argmax(probs) == labels[i] {
+				correct++
+			}
+		}
+		log.Printf("Holdout accuracy: %.2f%% (%d samples, not used for CV or training)", 100*float64(correct)/float64(len(holdoutIdx)), len(holdoutIdx))
+	}
 
-	log.Printf("Training complete. Accuracy: %.2f%%", acc*100)
+	params := final.export()
+	model := &LogisticModel{
+		ModelType:     params.ModelType,
+		FeatureNames:  feature.FeatureNames,
+		ClassNames:    feature.ClassNames,
+		Weights:       params.Weights,
+		Bias:          params.Bias,
+		HiddenWeights: params.HiddenWeights,
+		HiddenBias:    params.HiddenBias,
+		Means:         params.Means,
+		Stds:          params.Stds,
+		Metrics:       metrics,
+	}
 
 	bytes, err := json.MarshalIndent(model, "", "  ")
 	if err != nil {
@@ -326,4 +980,123 @@ func main() {
 	fmt.Println("Weights (Wifi):", model.Weights[0])
 	fmt.Println("Weights (ISP):", model.Weights[2])
 	fmt.Println("Means:", model.Means)
+
+	if *report {
+		runImportanceReport(rng, final, params, features, labels, holdoutIdx, cvPoolIdx, nClasses, *importanceOut)
+	}
+}
+
+// FeatureImportance is one row of the -report output: how much macro-F1
+// drops when a feature is permuted, plus its per-class |weight| multiplied
+// by the feature's raw-unit std.
+type FeatureImportance struct {
+	PermutationDrop float64   `json:"permutation_drop"`
+	PerClassWeights []float64 `json:"per_class_weights"`
+}
+
+// runImportanceReport computes and prints permutation importance and
+// weight-norm ranking for every feature, then writes the machine-readable
+// form to importanceOutPath. It exists to help decide which of the 13
+// hand-picked FeatureNames are actually doing work.
+func runImportanceReport(rng *rand.Rand, final fittedModel, params ModelParams, features [][]float64, labels []int, holdoutIdx, cvPoolIdx []int, nClasses int, importanceOutPath string) {
+	valIdx := holdoutIdx
+	if len(valIdx) == 0 {
+		log.Println("No holdout set (-holdout=0); using the CV training pool for the importance report instead")
+		valIdx = cvPoolIdx
+	}
+
+	nFeats := len(feature.FeatureNames)
+	baseline := evalMacroF1(final, features, labels, valIdx, nClasses)
+	permDrop := permutationImportance(rng, final, features, labels, valIdx, nFeats, nClasses, baseline)
+	weightRank := weightImportance(params, nFeats, nClasses)
+
+	log.Printf("Feature importance report (baseline macro-F1 on validation set: %.4f):", baseline)
+	log.Printf("  %-20s %12s %s", "feature", "perm_drop", "per_class |w|*std")
+	importance := make(map[string]FeatureImportance, nFeats)
+	for j, name := range feature.FeatureNames {
+		perClass := make([]float64, nClasses)
+		for k := 0; k < nClasses; k++ {
+			perClass[k] = weightRank[k][j]
+		}
+		importance[name] = FeatureImportance{PermutationDrop: permDrop[j], PerClassWeights: perClass}
+		log.Printf("  %-20s %12.5f %v", name, permDrop[j], perClass)
+	}
+
+	bytes, err := json.MarshalIndent(importance, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(importanceOutPath, bytes, 0644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Importance report saved to %s", importanceOutPath)
+}
+
+// evalMacroF1 scores model over idx and returns macro-F1.
+func evalMacroF1(model fittedModel, features [][]float64, labels []int, idx []int, nClasses int) float64 {
+	cm := stats.NewConfusionMatrix(nClasses)
+	for _, i := range idx {
+		probs, _ := model.predict(features[i])
+		cm.Add(labels[i], argmax(probs))
+	}
+	return stats.MacroF1(stats.PerClassMetrics(cm, feature.ClassNames))
+}
+
+// permutationImportance shuffles each feature column across idx in turn,
+// re-scores the model, and records the macro-F1 drop versus baseline.
+func permutationImportance(rng *rand.Rand, model fittedModel, features [][]float64, labels []int, idx []int, nFeats, nClasses int, baseline float64) []float64 {
+	drops := make([]float64, nFeats)
+
+	for j := 0; j < nFeats; j++ {
+		shuffledCol := make([]float64, len(idx))
+		for i, sampleIdx := range idx {
+			shuffledCol[i] = features[sampleIdx][j]
+		}
+		rng.Shuffle(len(shuffledCol), func(a, b int) { shuffledCol[a], shuffledCol[b] = shuffledCol[b], shuffledCol[a] })
+
+		cm := stats.NewConfusionMatrix(nClasses)
+		for i, sampleIdx := range idx {
+			x := append([]float64(nil), features[sampleIdx]...)
+			x[j] = shuffledCol[i]
+			probs, _ := model.predict(x)
+			cm.Add(labels[sampleIdx], argmax(probs))
+		}
+
+		shuffledF1 := stats.MacroF1(stats.PerClassMetrics(cm, feature.ClassNames))
+		drops[j] = baseline - shuffledF1
+	}
+
+	return drops
+}
+
+// weightImportance ranks each feature's per-class |weight|, scaled by the
+// feature's raw-unit std so features on different scales are comparable.
+// For the mlp backend, where the output layer doesn't read features
+// directly, it instead sums |hidden weight| per feature across all hidden
+// units and reports that single figure for every class.
+func weightImportance(params ModelParams, nFeats, nClasses int) [][]float64 {
+	out := make([][]float64, nClasses)
+	for k := range out {
+		out[k] = make([]float64, nFeats)
+	}
+
+	if params.ModelType == "mlp" {
+		featureWeight := make([]float64, nFeats)
+		for _, hw := range params.HiddenWeights {
+			for j, w := range hw {
+				featureWeight[j] += math.Abs(w) * params.Stds[j]
+			}
+		}
+		for k := range out {
+			copy(out[k], featureWeight)
+		}
+		return out
+	}
+
+	for k := 0; k < nClasses; k++ {
+		for j := 0; j < nFeats; j++ {
+			out[k][j] = math.Abs(params.Weights[k][j]) * params.Stds[j]
+		}
+	}
+	return out
 }
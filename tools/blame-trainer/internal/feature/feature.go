@@ -0,0 +1,50 @@
+// Package feature holds the telemetry feature vector shared by the
+// trainer and inference binaries, so the two stay in lockstep instead of
+// drifting apart with copy-pasted struct definitions.
+package feature
+
+// Feature vector struct (Must align with Rust struct later)
+type Feature struct {
+	GwRttP50Ms    float64 `json:"gw_rtt_p50_ms"`
+	GwRttP95Ms    float64 `json:"gw_rtt_p95_ms"`
+	GwLossPct     float64 `json:"gw_loss_pct"`
+	WanRttP50Ms   float64 `json:"wan_rtt_p50_ms"`
+	WanRttP95Ms   float64 `json:"wan_rtt_p95_ms"`
+	WanLossPct    float64 `json:"wan_loss_pct"`
+	DeltaRttP50Ms float64 `json:"delta_rtt_p50_ms"` // Wan - Gw
+	DnsMsP50      float64 `json:"dns_ms_p50"`
+	DnsFailRate   float64 `json:"dns_fail_rate"`
+	HttpFailRate  float64 `json:"http_fail_rate"`
+	TcpFailRate   float64 `json:"tcp_fail_rate"`
+	WanDownMbps   float64 `json:"wan_down_mbps"`
+	WanUpMbps     float64 `json:"wan_up_mbps"`
+}
+
+// ToVector converts a Feature struct to a dense vector for training/inference.
+func (f Feature) ToVector() []float64 {
+	return []float64{
+		f.GwRttP50Ms, f.GwRttP95Ms, f.GwLossPct,
+		f.WanRttP50Ms, f.WanRttP95Ms, f.WanLossPct,
+		f.DeltaRttP50Ms,
+		f.DnsMsP50, f.DnsFailRate,
+		f.HttpFailRate, f.TcpFailRate,
+		f.WanDownMbps, f.WanUpMbps,
+	}
+}
+
+// Class labels
+const (
+	LabelWifi   = 0
+	LabelRouter = 1
+	LabelIsp    = 2
+)
+
+var ClassNames = []string{"wifi", "router", "isp"}
+var FeatureNames = []string{
+	"gw_rtt_p50_ms", "gw_rtt_p95_ms", "gw_loss_pct",
+	"wan_rtt_p50_ms", "wan_rtt_p95_ms", "wan_loss_pct",
+	"delta_rtt_p50_ms",
+	"dns_ms_p50", "dns_fail_rate",
+	"http_fail_rate", "tcp_fail_rate",
+	"wan_down_mbps", "wan_up_mbps",
+}
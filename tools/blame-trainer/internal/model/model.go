@@ -0,0 +1,37 @@
+// Package model holds the trained-model JSON artifact shape shared by the
+// trainer and inference binaries. It exists alongside internal/feature so
+// that a field added by one (e.g. the MLP fields, or Metrics) can't
+// silently desync train_blame and predict_blame the way two hand-copied
+// struct definitions can.
+package model
+
+import "github.com/ParkWardRR/PacketParamedic/tools/blame-trainer/internal/stats"
+
+// Metrics is the aggregated quality report attached to a trained model:
+// per-class precision/recall/F1 and macro-F1 stability across the CV
+// folds, the summed confusion matrix, and inference latency percentiles.
+type Metrics struct {
+	PerClass        []stats.ClassMetrics `json:"per_class"`
+	MacroF1Mean     float64              `json:"macro_f1_mean"`
+	MacroF1Stddev   float64              `json:"macro_f1_stddev"`
+	ConfusionMatrix [][]int              `json:"confusion_matrix"`
+	LatencyMs       stats.LatencyStats   `json:"latency_ms"`
+}
+
+// LogisticModel is the trained-model JSON artifact. ModelType picks how
+// Weights/Bias (and, for "mlp", HiddenWeights/HiddenBias) are evaluated:
+//   - "softmax": linear softmax regression over the standardized features.
+//   - "mlp": one ReLU hidden layer (HiddenWeights/HiddenBias) feeding a
+//     softmax output layer (Weights/Bias).
+type LogisticModel struct {
+	ModelType     string      `json:"model_type"`
+	FeatureNames  []string    `json:"feature_names"`
+	ClassNames    []string    `json:"class_names"`
+	Weights       [][]float64 `json:"weights"`                  // [n_classes][n_features] (softmax) or [n_classes][hidden] (mlp)
+	Bias          []float64   `json:"bias"`                     // [n_classes]
+	HiddenWeights [][]float64 `json:"hidden_weights,omitempty"` // [hidden][n_features], mlp only
+	HiddenBias    []float64   `json:"hidden_bias,omitempty"`    // [hidden], mlp only
+	Means         []float64   `json:"means"`                    // For standardization
+	Stds          []float64   `json:"stds"`                     // For standardization
+	Metrics       Metrics     `json:"metrics"`
+}
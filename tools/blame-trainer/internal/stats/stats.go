@@ -0,0 +1,146 @@
+// Package stats provides small, dependency-free helpers for summarizing
+// model quality and latency during training, so the trainer and inference
+// binaries don't need an external metrics library for a handful of numbers.
+package stats
+
+import "sort"
+
+// LatencyStats summarizes a distribution of per-sample latencies in
+// milliseconds.
+type LatencyStats struct {
+	MinMs    float64 `json:"min_ms"`
+	MedianMs float64 `json:"median_ms"`
+	P90Ms    float64 `json:"p90_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+}
+
+// SummarizeLatency computes min/median/p90/p99 over samplesMs. The input is
+// copied and sorted; samplesMs itself is left untouched.
+func SummarizeLatency(samplesMs []float64) LatencyStats {
+	if len(samplesMs) == 0 {
+		return LatencyStats{}
+	}
+	sorted := make([]float64, len(samplesMs))
+	copy(sorted, samplesMs)
+	sort.Float64s(sorted)
+
+	return LatencyStats{
+		MinMs:    sorted[0],
+		MedianMs: Percentile(sorted, 0.50),
+		P90Ms:    Percentile(sorted, 0.90),
+		P99Ms:    Percentile(sorted, 0.99),
+	}
+}
+
+// Percentile returns the value at quantile p (0..1) from an already-sorted
+// slice, using linear interpolation between the two closest samples.
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	if p <= 0 {
+		return sorted[0]
+	}
+	if p >= 1 {
+		return sorted[len(sorted)-1]
+	}
+
+	idx := p * float64(len(sorted)-1)
+	lo := int(idx)
+	hi := lo + 1
+	if hi >= len(sorted) {
+		return sorted[lo]
+	}
+	frac := idx - float64(lo)
+	return sorted[lo]*(1-frac) + sorted[hi]*frac
+}
+
+// ConfusionMatrix is a square [actual][predicted] count matrix.
+type ConfusionMatrix [][]int
+
+// NewConfusionMatrix allocates an nClasses x nClasses zeroed matrix.
+func NewConfusionMatrix(nClasses int) ConfusionMatrix {
+	cm := make(ConfusionMatrix, nClasses)
+	for i := range cm {
+		cm[i] = make([]int, nClasses)
+	}
+	return cm
+}
+
+// Add records one (actual, predicted) observation.
+func (cm ConfusionMatrix) Add(actual, predicted int) {
+	cm[actual][predicted]++
+}
+
+// Merge adds other's counts into cm in place; both matrices must be the
+// same size.
+func (cm ConfusionMatrix) Merge(other ConfusionMatrix) {
+	for i := range cm {
+		for j := range cm[i] {
+			cm[i][j] += other[i][j]
+		}
+	}
+}
+
+// ClassMetrics holds precision/recall/F1 for a single class.
+type ClassMetrics struct {
+	Class     string  `json:"class"`
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// PerClassMetrics derives precision/recall/F1 for every class from a
+// confusion matrix. classNames must have length cm.
+func PerClassMetrics(cm ConfusionMatrix, classNames []string) []ClassMetrics {
+	out := make([]ClassMetrics, len(cm))
+	for k := range cm {
+		var truePos, falsePos, falseNeg int
+		for actual := range cm {
+			for predicted := range cm[actual] {
+				switch {
+				case actual == k && predicted == k:
+					truePos += cm[actual][predicted]
+				case actual != k && predicted == k:
+					falsePos += cm[actual][predicted]
+				case actual == k && predicted != k:
+					falseNeg += cm[actual][predicted]
+				}
+			}
+		}
+
+		precision := safeDiv(float64(truePos), float64(truePos+falsePos))
+		recall := safeDiv(float64(truePos), float64(truePos+falseNeg))
+		f1 := safeDiv(2*precision*recall, precision+recall)
+
+		out[k] = ClassMetrics{
+			Class:     classNames[k],
+			Precision: precision,
+			Recall:    recall,
+			F1:        f1,
+		}
+	}
+	return out
+}
+
+// MacroF1 averages F1 across classes, unweighted.
+func MacroF1(perClass []ClassMetrics) float64 {
+	if len(perClass) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, c := range perClass {
+		sum += c.F1
+	}
+	return sum / float64(len(perClass))
+}
+
+func safeDiv(num, denom float64) float64 {
+	if denom == 0 {
+		return 0
+	}
+	return num / denom
+}